@@ -0,0 +1,250 @@
+package quota
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTickInterval  = 30 * time.Second
+	defaultAdditiveStep  = 1
+	defaultBackoffFactor = 0.75
+)
+
+// BackoffSignal reports whether some external condition - CPU saturation,
+// memory pressure, upstream error rate, p99 latency, and so on - indicates
+// that concurrency should be reduced. It should return quickly, as it is
+// polled from the enforcer's adjustment loop.
+type BackoffSignal func() bool
+
+// adaptiveWaiter is an entry in an AdaptiveQuotaEnforcer's waiter queue.
+// ready is closed once a slot has been reserved on the waiter's behalf.
+type adaptiveWaiter struct {
+	ready chan struct{}
+}
+
+// AdaptiveQuotaEnforcer is a counting semaphore whose limit is not fixed at
+// construction time but recomputed periodically within a configured
+// [min, max] range, using an additive-increase/multiplicative-decrease
+// (AIMD) policy driven by one or more registered BackoffSignals: on each
+// tick, if no signal reports backoff, the limit grows by a fixed step; if
+// any signal reports backoff, the limit shrinks by a configurable factor.
+// This gives operators adaptive concurrency limiting without pinning a
+// specific policy to a specific signal.
+//
+// When the limit shrinks below the number of outstanding tickets, new
+// Acquire calls block until enough Releases bring usage back under the
+// limit; in-flight tickets are never revoked.
+type AdaptiveQuotaEnforcer struct {
+	min, max int64
+	step     int64
+	factor   float64
+	interval time.Duration
+
+	mu      sync.Mutex
+	limit   int64
+	cur     int64
+	waiters list.List // of *adaptiveWaiter
+
+	signalsMu sync.Mutex
+	signals   map[string]BackoffSignal
+
+	changes chan int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// AdaptiveQuotaOption configures an AdaptiveQuotaEnforcer at construction
+// time.
+type AdaptiveQuotaOption func(*AdaptiveQuotaEnforcer)
+
+// WithTickInterval overrides the default interval at which the limit is
+// recomputed.
+func WithTickInterval(d time.Duration) AdaptiveQuotaOption {
+	return func(q *AdaptiveQuotaEnforcer) { q.interval = d }
+}
+
+// WithAdditiveStep overrides the amount the limit grows by on ticks where
+// no BackoffSignal reports backoff.
+func WithAdditiveStep(n int64) AdaptiveQuotaOption {
+	return func(q *AdaptiveQuotaEnforcer) { q.step = n }
+}
+
+// WithBackoffFactor overrides the multiplicative factor applied to the
+// limit on ticks where at least one BackoffSignal reports backoff. factor
+// should be in (0, 1).
+func WithBackoffFactor(factor float64) AdaptiveQuotaOption {
+	return func(q *AdaptiveQuotaEnforcer) { q.factor = factor }
+}
+
+// NewAdaptiveQuotaEnforcer returns an AdaptiveQuotaEnforcer that starts at
+// max and adjusts itself within [min, max]. Callers should call Close when
+// the enforcer is no longer needed to stop its background adjustment loop.
+func NewAdaptiveQuotaEnforcer(min, max int64, opts ...AdaptiveQuotaOption) *AdaptiveQuotaEnforcer {
+	q := &AdaptiveQuotaEnforcer{
+		min:      min,
+		max:      max,
+		limit:    max,
+		step:     defaultAdditiveStep,
+		factor:   defaultBackoffFactor,
+		interval: defaultTickInterval,
+		signals:  make(map[string]BackoffSignal),
+		changes:  make(chan int64, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	go q.run()
+	return q
+}
+
+// RegisterBackoffSignal registers fn under name, so it's consulted on every
+// adjustment tick. Registering a second signal under the same name replaces
+// the first.
+func (q *AdaptiveQuotaEnforcer) RegisterBackoffSignal(name string, fn BackoffSignal) {
+	q.signalsMu.Lock()
+	defer q.signalsMu.Unlock()
+	q.signals[name] = fn
+}
+
+// SetLimit overrides the current limit directly, clamped to [min, max].
+// It's primarily useful for tests and for subsystems (a memory watcher, a
+// load shedder) that want to react immediately rather than waiting for the
+// next tick.
+func (q *AdaptiveQuotaEnforcer) SetLimit(n int64) {
+	q.mu.Lock()
+	q.setLimitLocked(clampInt64(n, q.min, q.max))
+	q.mu.Unlock()
+}
+
+// LimitChanges returns a channel that receives the new limit each time it
+// changes, for metrics and observability. Sends are non-blocking: a slow
+// consumer may miss an intermediate value, but will see the current limit
+// on the next change.
+func (q *AdaptiveQuotaEnforcer) LimitChanges() <-chan int64 {
+	return q.changes
+}
+
+// Close stops the enforcer's background adjustment loop. Outstanding
+// tickets remain valid and must still be released.
+func (q *AdaptiveQuotaEnforcer) Close() {
+	close(q.stop)
+	<-q.done
+}
+
+func (q *AdaptiveQuotaEnforcer) Acquire(ctx context.Context) Ticket {
+	q.mu.Lock()
+	if q.limit-q.cur > 0 && q.waiters.Len() == 0 {
+		q.cur++
+		q.mu.Unlock()
+		return emptyTicket{}
+	}
+
+	w := &adaptiveWaiter{ready: make(chan struct{})}
+	elem := q.waiters.PushBack(w)
+	q.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		q.mu.Lock()
+		select {
+		case <-w.ready:
+			q.cur--
+			q.notifyWaitersLocked()
+		default:
+			q.waiters.Remove(elem)
+		}
+		q.mu.Unlock()
+		return nil
+	case <-w.ready:
+		return emptyTicket{}
+	}
+}
+
+func (q *AdaptiveQuotaEnforcer) Release(_ Ticket) {
+	q.mu.Lock()
+	q.cur--
+	q.notifyWaitersLocked()
+	q.mu.Unlock()
+}
+
+// notifyWaitersLocked wakes waiters at the head of the queue while there's
+// room under the current limit. q.mu must be held.
+func (q *AdaptiveQuotaEnforcer) notifyWaitersLocked() {
+	for q.limit-q.cur > 0 {
+		elem := q.waiters.Front()
+		if elem == nil {
+			return
+		}
+		q.cur++
+		q.waiters.Remove(elem)
+		close(elem.Value.(*adaptiveWaiter).ready)
+	}
+}
+
+func (q *AdaptiveQuotaEnforcer) run() {
+	defer close(q.done)
+	t := time.NewTicker(q.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-t.C:
+			q.adjust()
+		}
+	}
+}
+
+func (q *AdaptiveQuotaEnforcer) adjust() {
+	backoff := q.anyBackoff()
+
+	q.mu.Lock()
+	next := q.limit + q.step
+	if backoff {
+		next = int64(math.Floor(float64(q.limit) * q.factor))
+	}
+	q.setLimitLocked(clampInt64(next, q.min, q.max))
+	q.mu.Unlock()
+}
+
+func (q *AdaptiveQuotaEnforcer) anyBackoff() bool {
+	q.signalsMu.Lock()
+	defer q.signalsMu.Unlock()
+	for _, fn := range q.signals {
+		if fn() {
+			return true
+		}
+	}
+	return false
+}
+
+// setLimitLocked applies a new limit, waking any waiters it newly admits
+// and emitting a change event. q.mu must be held.
+func (q *AdaptiveQuotaEnforcer) setLimitLocked(n int64) {
+	if n == q.limit {
+		return
+	}
+	q.limit = n
+	q.notifyWaitersLocked()
+	select {
+	case q.changes <- n:
+	default:
+	}
+}
+
+func clampInt64(n, min, max int64) int64 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -1,8 +1,9 @@
 package quota
 
 import (
+	"container/list"
 	"context"
-	"sync/atomic"
+	"sync"
 )
 
 // Ticket is a sentinel type used to represent a request ticket.
@@ -13,8 +14,10 @@ type Ticket interface{}
 // Enforcer implementations allow for the enforcement of request quotas.
 type Enforcer interface {
 	// Acquire a request token. This blocks until a token is available,
-	// and returns whether one was granted. The only time a token is not
-	// granted is when the provided context expires before one is available.
+	// and returns the Ticket to later pass to Release, or nil if none was
+	// granted - for example because the provided context expired before
+	// one became available, or the enforcer fast-failed due to a full
+	// waiter queue.
 	Acquire(context.Context) Ticket
 	// Release a request token.
 	Release(Ticket)
@@ -22,40 +25,99 @@ type Enforcer interface {
 
 type emptyTicket struct{}
 
+// staticWaiter is an entry in a StaticQuotaEnforcer's waiter queue. ready is
+// closed once a slot has been reserved on the waiter's behalf.
+type staticWaiter struct {
+	ready chan struct{}
+}
+
 // StaticQuotaEnforcer implements a blocking, counting semaphore that enforces
-// a static maximum number of concurrent requests. Acquire should be
-// called synchronously; Release may be called asynchronously.
+// a static maximum number of concurrent requests, admitting waiters in
+// strict FIFO order. Acquire and Release may be called concurrently from
+// multiple goroutines.
 type StaticQuotaEnforcer struct {
-	n    atomic.Int64
-	wait chan struct{}
+	limit int64
+	// maxQueueDepth bounds the waiter queue; 0 means unbounded. See
+	// NewStaticQuotaEnforcerWithQueue.
+	maxQueueDepth uint32
+
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of *staticWaiter
 }
 
 func (q *StaticQuotaEnforcer) Acquire(ctx context.Context) Ticket {
-	if q.n.Add(-1) < 0 {
-		// We ran out of quota. Block until a release happens or our
-		// context is canceled.
+	q.mu.Lock()
+	if q.limit-q.cur > 0 && q.waiters.Len() == 0 {
+		q.cur++
+		q.mu.Unlock()
+		return emptyTicket{}
+	}
+	if q.maxQueueDepth > 0 && uint32(q.waiters.Len()) >= q.maxQueueDepth {
+		// The queue is already full. Fail fast so callers can return
+		// RESOURCE_EXHAUSTED instead of piling up goroutines behind an
+		// overloaded server.
+		q.mu.Unlock()
+		return nil
+	}
+
+	w := &staticWaiter{ready: make(chan struct{})}
+	elem := q.waiters.PushBack(w)
+	q.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		q.mu.Lock()
 		select {
-		case <-ctx.Done():
-			return false
-		case <-q.wait:
+		case <-w.ready:
+			// We were granted the slot in the window between ctx expiring
+			// and us acquiring the lock. Don't leak it: give it back and
+			// wake whoever's next.
+			q.cur--
+			q.notifyWaitersLocked()
+		default:
+			q.waiters.Remove(elem)
 		}
+		q.mu.Unlock()
+		return nil
+	case <-w.ready:
+		return emptyTicket{}
 	}
-	return emptyTicket{}
 }
 
 func (q *StaticQuotaEnforcer) Release(_ Ticket) {
-	// N.B. the "<= 0" check below should allow for this to work with multiple
-	// concurrent calls to acquire, but also note that with synchronous calls to
-	// acquire, as our system does, n will never be less than -1.  There are
-	// fairness issues (queuing) to consider if this was to be generalized.
-	if q.n.Add(1) <= 0 {
-		// An acquire was waiting on us.  Unblock it.
-		q.wait <- struct{}{}
+	q.mu.Lock()
+	q.cur--
+	q.notifyWaitersLocked()
+	q.mu.Unlock()
+}
+
+// notifyWaitersLocked wakes waiters at the head of the queue while there's
+// room under the limit. q.mu must be held.
+func (q *StaticQuotaEnforcer) notifyWaitersLocked() {
+	for q.limit-q.cur > 0 {
+		elem := q.waiters.Front()
+		if elem == nil {
+			return
+		}
+		q.cur++
+		q.waiters.Remove(elem)
+		close(elem.Value.(*staticWaiter).ready)
 	}
 }
 
+// NewStaticQuotaEnforcer returns a StaticQuotaEnforcer admitting at most n
+// concurrent requests, with an unbounded waiter queue.
 func NewStaticQuotaEnforcer(n uint32) *StaticQuotaEnforcer {
-	a := &StaticQuotaEnforcer{wait: make(chan struct{}, 1)}
-	a.n.Store(int64(n))
-	return a
+	return &StaticQuotaEnforcer{limit: int64(n)}
+}
+
+// NewStaticQuotaEnforcerWithQueue returns a StaticQuotaEnforcer admitting at
+// most limit concurrent requests, queuing at most maxQueueDepth additional
+// waiters. Once the queue is full, Acquire returns a nil Ticket immediately
+// instead of blocking, so callers can fail fast rather than queue
+// indefinitely behind an overloaded server. A maxQueueDepth of 0 leaves the
+// queue unbounded, as with NewStaticQuotaEnforcer.
+func NewStaticQuotaEnforcerWithQueue(limit, maxQueueDepth uint32) *StaticQuotaEnforcer {
+	return &StaticQuotaEnforcer{limit: int64(limit), maxQueueDepth: maxQueueDepth}
 }
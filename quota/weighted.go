@@ -0,0 +1,136 @@
+package quota
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// WeightedEnforcer is implemented by Enforcers that can admit requests of
+// varying cost, rather than treating every request as a single unit of
+// quota. This lets expensive RPCs (large messages, pack-object-style
+// handlers) consume proportionally more of the budget than cheap ones.
+type WeightedEnforcer interface {
+	Enforcer
+	// AcquireN acquires n units of quota, blocking until they're available
+	// or ctx is done. It returns nil if ctx expired before n units were
+	// available, or if n exceeds the enforcer's total capacity.
+	AcquireN(ctx context.Context, n int64) Ticket
+	// ReleaseN releases a Ticket obtained from AcquireN.
+	ReleaseN(Ticket)
+}
+
+// weightedTicket records how much capacity to return to the enforcer on
+// release.
+type weightedTicket struct {
+	n int64
+}
+
+// weightedWaiter is an entry in a WeightedQuotaEnforcer's waiter queue.
+// ready is closed once n units have been reserved on the waiter's behalf.
+type weightedWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// WeightedQuotaEnforcer is a weighted semaphore: it enforces a static total
+// capacity, but unlike StaticQuotaEnforcer, individual acquisitions may
+// consume more than one unit of it. Waiters are served in strict FIFO
+// order, so a large request that doesn't yet fit will hold up smaller,
+// later requests rather than being starved by them.
+type WeightedQuotaEnforcer struct {
+	size int64
+
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of *weightedWaiter
+}
+
+// NewWeightedQuotaEnforcer returns a WeightedQuotaEnforcer with the given
+// total capacity.
+func NewWeightedQuotaEnforcer(size int64) *WeightedQuotaEnforcer {
+	return &WeightedQuotaEnforcer{size: size}
+}
+
+// Acquire acquires a single unit of quota. It is equivalent to
+// AcquireN(ctx, 1).
+func (q *WeightedQuotaEnforcer) Acquire(ctx context.Context) Ticket {
+	return q.AcquireN(ctx, 1)
+}
+
+// AcquireN acquires n units of quota, blocking until they're available or
+// ctx is done.
+func (q *WeightedQuotaEnforcer) AcquireN(ctx context.Context, n int64) Ticket {
+	q.mu.Lock()
+	if n > q.size {
+		// This request can never be satisfied; don't enqueue a waiter that
+		// would block forever.
+		q.mu.Unlock()
+		return nil
+	}
+	if q.size-q.cur >= n && q.waiters.Len() == 0 {
+		q.cur += n
+		q.mu.Unlock()
+		return weightedTicket{n: n}
+	}
+
+	w := &weightedWaiter{n: n, ready: make(chan struct{})}
+	elem := q.waiters.PushBack(w)
+	q.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		q.mu.Lock()
+		select {
+		case <-w.ready:
+			// We were granted the slot in the window between ctx expiring
+			// and us acquiring the lock. Don't leak it: give it back and
+			// wake whoever's next.
+			q.cur -= n
+			q.notifyWaitersLocked()
+		default:
+			q.waiters.Remove(elem)
+		}
+		q.mu.Unlock()
+		return nil
+	case <-w.ready:
+		return weightedTicket{n: n}
+	}
+}
+
+// Release releases a Ticket obtained from Acquire or AcquireN.
+func (q *WeightedQuotaEnforcer) Release(t Ticket) {
+	q.ReleaseN(t)
+}
+
+// ReleaseN releases a Ticket obtained from AcquireN.
+func (q *WeightedQuotaEnforcer) ReleaseN(t Ticket) {
+	wt, ok := t.(weightedTicket)
+	if !ok {
+		return
+	}
+	q.mu.Lock()
+	q.cur -= wt.n
+	q.notifyWaitersLocked()
+	q.mu.Unlock()
+}
+
+// notifyWaitersLocked wakes waiters at the head of the queue whose request
+// now fits, in FIFO order. It stops at the first waiter that still doesn't
+// fit, so a later, smaller request can never jump the queue ahead of an
+// earlier, larger one. q.mu must be held.
+func (q *WeightedQuotaEnforcer) notifyWaitersLocked() {
+	for {
+		elem := q.waiters.Front()
+		if elem == nil {
+			return
+		}
+		w := elem.Value.(*weightedWaiter)
+		if q.size-q.cur < w.n {
+			return
+		}
+		q.cur += w.n
+		q.waiters.Remove(elem)
+		close(w.ready)
+	}
+}